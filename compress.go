@@ -0,0 +1,292 @@
+//
+// compress.go
+//
+// Copyright (c) 2018 Markku Rossi
+//
+// All rights reserved.
+//
+
+package sldc
+
+import (
+	"fmt"
+)
+
+// Output implements an output bit stream. It is the mirror image of
+// Input: bits are packed most significant bit first into a byte
+// buffer.
+type Output struct {
+	data []byte
+	cur  byte
+	bits int
+}
+
+// NewOutput creates a new output object.
+func NewOutput() *Output {
+	return new(Output)
+}
+
+// Put writes the low `bits` bits of val to the output, most
+// significant bit first.
+func (out *Output) Put(val uint32, bits int) {
+	for bits > 0 {
+		avail := 8 - out.bits
+		take := bits
+		if take > avail {
+			take = avail
+		}
+		shift := uint(bits - take)
+		b := byte((val >> shift) & (1<<uint(take) - 1))
+		out.cur |= b << uint(avail-take)
+		out.bits += take
+		bits -= take
+
+		if out.bits == 8 {
+			out.data = append(out.data, out.cur)
+			out.cur = 0
+			out.bits = 0
+		}
+	}
+}
+
+// Ctrl writes a control word to the output.
+func (out *Output) Ctrl(c Ctrl) {
+	out.Put(0x1ff, 9)
+	out.Put(uint32(c), 4)
+}
+
+// Align pads the output to the next byte and then to the next four
+// byte boundary, mirroring Input.Align.
+func (out *Output) Align() {
+	if out.bits != 0 {
+		out.Put(0, 8-out.bits)
+	}
+	for len(out.data)%4 != 0 {
+		out.data = append(out.data, 0)
+	}
+}
+
+// Bytes returns the accumulated output, padding any partial trailing
+// byte with zero bits.
+func (out *Output) Bytes() []byte {
+	if out.bits != 0 {
+		out.Put(0, 8-out.bits)
+	}
+	return out.data
+}
+
+// writeLiteral writes a literal data symbol. The encoding is the same
+// in both Scheme 1 and Scheme 2: a `0` prefix followed by the 8-bit
+// value.
+func (out *Output) writeLiteral(b byte) {
+	out.Put(0, 1)
+	out.Put(uint32(b), 8)
+}
+
+// writeCopy writes a copy pointer symbol for the given scheme, using
+// the shortest prefix code that covers length.
+func (out *Output) writeCopy(scheme Scheme, length, displacement int) {
+	prefix, prefixBits, base, fieldBits := lengthCode(scheme, length)
+	out.Put(1, 1)
+	out.Put(prefix, prefixBits)
+	out.Put(uint32(length-base), fieldBits)
+	out.Put(uint32(displacement), 10)
+}
+
+// lengthCode returns the prefix value, prefix bit width, base length
+// and field bit width used to encode length in the given scheme. It is
+// the encoder-side counterpart of the tables used by Decompress.
+func lengthCode(scheme Scheme, length int) (prefix uint32, prefixBits, base, fieldBits int) {
+	if scheme == Scheme2 {
+		// Scheme 2 length alphabet: `0 xxxx` for lengths 2-17 and
+		// `1 xxxxxxxx` for lengths 18-271.
+		if length < 18 {
+			return 0, 1, 2, 4
+		}
+		return 1, 1, 18, 8
+	}
+
+	// Scheme 1 length alphabet: 0x, 10xx, 110xxx, 1110xxxx and
+	// 1111xxxxxxxx for the five length bands.
+	switch {
+	case length < 4:
+		return 0, 1, 2, 1
+	case length < 8:
+		return 2, 2, 4, 2
+	case length < 16:
+		return 6, 3, 8, 3
+	case length < 32:
+		return 14, 4, 16, 4
+	default:
+		return 15, 4, 32, 8
+	}
+}
+
+// copyCost returns the number of bits that a copy pointer symbol for
+// length costs when encoded with the given scheme.
+func copyCost(scheme Scheme, length int) int {
+	_, prefixBits, _, fieldBits := lengthCode(scheme, length)
+	return 1 + prefixBits + fieldBits + 10
+}
+
+const (
+	minMatchLen = 2
+	maxMatchLen = 271
+	windowSize  = 1024
+	hashBits    = 15
+	hashSize    = 1 << hashBits
+)
+
+// symbol is a single literal or copy pointer decision produced by the
+// matcher. displacement is the match's start position within the
+// slice passed to findMatches; callers combine it with whatever
+// offset that slice had within the history before taking it modulo
+// windowSize to get the final 10-bit displacement.
+type symbol struct {
+	literal      bool
+	b            byte
+	length       int
+	displacement int
+}
+
+// hash2 is a rolling hash over two input bytes, used to seed the
+// matcher's candidate table.
+func hash2(a, b byte) uint32 {
+	h := uint32(a)<<8 | uint32(b)
+	return (h * 2654435761) >> (32 - hashBits)
+}
+
+// findMatches scans data and returns the literal/copy-pointer symbols
+// needed to reproduce it, using a single-candidate rolling hash table
+// to locate matches of length 2..271 within the last 1024 bytes and
+// greedily taking the longest one available at each position.
+func findMatches(data []byte) []symbol {
+	var symbols []symbol
+	table := make([]int, hashSize)
+	for i := range table {
+		table[i] = -1
+	}
+
+	pos := 0
+	for pos < len(data) {
+		length := 0
+		matchPos := 0
+
+		if pos+minMatchLen <= len(data) {
+			h := hash2(data[pos], data[pos+1])
+			cand := table[h]
+			table[h] = pos
+
+			if cand >= 0 && pos-cand <= windowSize {
+				max := maxMatchLen
+				if len(data)-pos < max {
+					max = len(data) - pos
+				}
+				n := 0
+				for n < max && data[cand+n] == data[pos+n] {
+					n++
+				}
+				if n >= minMatchLen {
+					length = n
+					matchPos = cand
+				}
+			}
+		}
+
+		if length >= minMatchLen {
+			symbols = append(symbols, symbol{
+				length:       length,
+				displacement: matchPos,
+			})
+			for i := 1; i < length && pos+i+minMatchLen <= len(data); i++ {
+				h := hash2(data[pos+i], data[pos+i+1])
+				table[h] = pos + i
+			}
+			pos += length
+		} else {
+			symbols = append(symbols, symbol{literal: true, b: data[pos]})
+			pos++
+		}
+	}
+	return symbols
+}
+
+const blockSymbols = 32
+
+// Compress compresses data into an SLDC stream. scheme selects the
+// initial compression scheme; the encoder switches between Scheme 1
+// and Scheme 2 on block boundaries whenever the other scheme would
+// encode that block's copy pointers in fewer bits.
+func Compress(data []byte, scheme Scheme) ([]byte, error) {
+	if scheme != Scheme1 && scheme != Scheme2 {
+		return nil, fmt.Errorf("Invalid scheme %d", scheme)
+	}
+
+	symbols := findMatches(data)
+
+	out := NewOutput()
+	active := scheme
+	out.Ctrl(resetCtrl(active))
+
+	for i := 0; i < len(symbols); i += blockSymbols {
+		end := i + blockSymbols
+		if end > len(symbols) {
+			end = len(symbols)
+		}
+		block := symbols[i:end]
+
+		best := bestScheme(block, active)
+		if best != active {
+			out.Ctrl(schemeCtrl(best))
+			active = best
+		}
+		for _, sym := range block {
+			if sym.literal {
+				out.writeLiteral(sym.b)
+			} else {
+				out.writeCopy(active, sym.length, sym.displacement%windowSize)
+			}
+		}
+	}
+
+	out.Ctrl(CtrlEOR)
+	out.Align()
+	return out.Bytes(), nil
+}
+
+// bestScheme picks the cheaper of Scheme1 and Scheme2 for block,
+// based on the total bits its copy pointers would cost under each
+// scheme. Ties are kept on the active scheme to avoid a needless
+// switch.
+func bestScheme(block []symbol, active Scheme) Scheme {
+	var cost1, cost2 int
+	for _, sym := range block {
+		if sym.literal {
+			continue
+		}
+		cost1 += copyCost(Scheme1, sym.length)
+		cost2 += copyCost(Scheme2, sym.length)
+	}
+	switch {
+	case cost1 < cost2:
+		return Scheme1
+	case cost2 < cost1:
+		return Scheme2
+	default:
+		return active
+	}
+}
+
+func resetCtrl(scheme Scheme) Ctrl {
+	if scheme == Scheme2 {
+		return CtrlReset2
+	}
+	return CtrlReset1
+}
+
+func schemeCtrl(scheme Scheme) Ctrl {
+	if scheme == Scheme2 {
+		return CtrlScheme2
+	}
+	return CtrlScheme1
+}
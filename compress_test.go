@@ -0,0 +1,82 @@
+//
+// compress_test.go
+//
+// Copyright (c) 2018 Markku Rossi
+//
+// All rights reserved.
+//
+
+package sldc
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestCompressDecompressRoundTrip(t *testing.T) {
+	corpus := map[string][]byte{
+		"empty":           {},
+		"single byte":     {0x42},
+		"no repetition":   []byte("the quick brown fox jumps over the lazy dog"),
+		"short repeats":   bytes.Repeat([]byte("ab"), 100),
+		"long repeats":    bytes.Repeat([]byte("abcdefgh"), 200),
+		"mostly zero":     make([]byte, 4096),
+		"random":          randomBytes(4096, 1),
+		"wide vocabulary": []byte(strings.Repeat("the quick brown fox jumps over the lazy dog. ", 50)),
+	}
+
+	for name, data := range corpus {
+		for _, scheme := range []Scheme{Scheme1, Scheme2} {
+			t.Run(fmt.Sprintf("%s/scheme%d", name, scheme), func(t *testing.T) {
+				compressed, err := Compress(data, scheme)
+				if err != nil {
+					t.Fatalf("Compress failed: %s", err)
+				}
+				got, err := Decompress(compressed)
+				if err != nil {
+					t.Fatalf("Decompress failed: %s", err)
+				}
+				if !bytes.Equal(got, data) {
+					t.Fatalf("round trip mismatch: got %d bytes, want %d bytes",
+						len(got), len(data))
+				}
+			})
+		}
+	}
+}
+
+func TestFindMatchesLengthTwo(t *testing.T) {
+	// "ab" recurs at offset 4, but the bytes right after it differ
+	// ("12" vs "34"), so the only match the matcher can take there is
+	// exactly length 2 - it must not require a 3-byte window to find it.
+	symbols := findMatches([]byte("ab12ab34"))
+	var gotLen2 bool
+	for _, sym := range symbols {
+		if !sym.literal && sym.length == 2 {
+			gotLen2 = true
+		}
+		if !sym.literal && sym.length < minMatchLen {
+			t.Fatalf("got copy pointer shorter than minMatchLen: %+v", sym)
+		}
+	}
+	if !gotLen2 {
+		t.Fatalf("expected at least one length-2 copy pointer, got %+v", symbols)
+	}
+}
+
+func TestCompressInvalidScheme(t *testing.T) {
+	_, err := Compress([]byte("data"), Scheme(0))
+	if err == nil {
+		t.Error("expected an error for an invalid scheme")
+	}
+}
+
+func randomBytes(n int, seed int64) []byte {
+	r := rand.New(rand.NewSource(seed))
+	data := make([]byte, n)
+	r.Read(data)
+	return data
+}
@@ -11,6 +11,7 @@
 package sldc
 
 import (
+	"bytes"
 	"encoding/hex"
 	"errors"
 	"fmt"
@@ -86,6 +87,10 @@ func (in *Input) Avail() int {
 
 // Get gets the specified number of bits from the input.
 func (in *Input) Get(bits int) (val uint32, err error) {
+	if in.ofs >= len(in.data) {
+		err = ErrTruncatedInput
+		return
+	}
 	if in.bits > bits {
 		left := in.bits - bits
 		val = uint32(in.data[in.ofs] >> uint(left))
@@ -156,8 +161,17 @@ func (in *Input) Ctrl() (Ctrl, error) {
 	return Ctrl(val), err
 }
 
+// BitOffset returns the absolute bit position of the next unread bit.
+func (in *Input) BitOffset() int64 {
+	return int64(in.ofs)*8 + int64(8-in.bits)
+}
+
 // Align aligns input to the next four byte boundary.
 func (in *Input) Align() error {
+	if in.bits != 8 {
+		in.ofs++
+		in.bits = 8
+	}
 	for (in.ofs%4) != 0 && in.ofs < len(in.data) {
 		in.ofs++
 	}
@@ -207,131 +221,235 @@ func (h *History) Reset() {
 	h.pos = 0
 }
 
-// Decompress decompresses the data.
-func Decompress(data []byte) ([]byte, error) {
-	input := NewInput(data)
-	scheme := Scheme1
+// scheme1Symbol decodes one Scheme 1 literal or copy pointer symbol
+// from in, applying it to history and appending any produced bytes to
+// result.
+func scheme1Symbol(in *Input, history *History, result *[]byte) error {
+	val, err := in.Get(1)
+	if err != nil {
+		return err
+	}
+	if val == 0 {
+		// Literal Data Symbols
+		val, err = in.Get(8)
+		if err != nil {
+			return err
+		}
+		history.Add(byte(val))
+		*result = append(*result, byte(val))
+		return nil
+	}
+
+	// Copy Pointer Data Symbols
+	var ones int
+	for ones = 0; ones < 4; ones++ {
+		val, err := in.Get(1)
+		if err != nil {
+			return err
+		}
+		if val == 0 {
+			break
+		}
+	}
+	base, bits := lengthBand(Scheme1, ones)
+	return copySymbol(in, history, result, base, bits)
+}
+
+// scheme2Symbol decodes one Scheme 2 literal or copy pointer symbol
+// from in, applying it to history and appending any produced bytes to
+// result.
+func scheme2Symbol(in *Input, history *History, result *[]byte) error {
+	val, err := in.Get(1)
+	if err != nil {
+		return err
+	}
+	if val == 0 {
+		// Literal Data Symbols
+		val, err = in.Get(8)
+		if err != nil {
+			return err
+		}
+		history.Add(byte(val))
+		*result = append(*result, byte(val))
+		return nil
+	}
+
+	// Copy Pointer Data Symbols
+	var ones int
+	for ones = 0; ones < 1; ones++ {
+		val, err := in.Get(1)
+		if err != nil {
+			return err
+		}
+		if val == 0 {
+			break
+		}
+	}
+	base, bits := lengthBand(Scheme2, ones)
+	return copySymbol(in, history, result, base, bits)
+}
+
+// lengthBand returns the base length and field bit width for a copy
+// pointer's length prefix, given the scheme's length alphabet and the
+// number of leading one-bits already read from that prefix. It is the
+// shared table behind scheme1Symbol, scheme2Symbol and the boundary
+// scanner used by DecompressParallel.
+func lengthBand(scheme Scheme, ones int) (base, bits int) {
+	if scheme == Scheme2 {
+		switch ones {
+		case 0:
+			// 0 xxxx
+			return 2, 4
+		default:
+			// 1 xxxxxxxx
+			return 18, 8
+		}
+	}
+	switch ones {
+	case 0:
+		// 0 x
+		return 2, 1
+	case 1:
+		// 10 xx
+		return 4, 2
+	case 2:
+		// 110 xxx
+		return 8, 3
+	case 3:
+		// 1110 xxxx
+		return 16, 4
+	default:
+		// 1111 xxxxxxxx
+		return 32, 8
+	}
+}
+
+// copySymbol reads a copy pointer's length field and 10-bit
+// displacement and replays the matched bytes from history into
+// result. base and bits describe the length field selected by the
+// caller's prefix code.
+func copySymbol(in *Input, history *History, result *[]byte, base, bits int) error {
+	val, err := in.Get(bits)
+	if err != nil {
+		return err
+	}
+	matchCount := base + int(val)
+	if matchCount > 271 {
+		return fmt.Errorf("Invalid match count %d", matchCount)
+	}
+	val, err = in.Get(10)
+	if err != nil {
+		return err
+	}
+	displacement := int(val)
+	var b byte
+	for matchCount > 0 {
+		b, displacement = history.Get(displacement)
+		history.Add(b)
+		*result = append(*result, b)
+		matchCount--
+	}
+	return nil
+}
+
+// decodeStep decodes one control word or data symbol from in,
+// updating scheme and history and appending any bytes produced (by a
+// literal or a copy pointer) to result. total is the number of bytes already decoded
+// across the whole session (all records), used to recognise an end
+// marker at the very start of the data. ctrl and isCtrl describe the
+// control word seen, if any.
+func decodeStep(in *Input, scheme *Scheme, history *History, result *[]byte, total int) (ctrl Ctrl, isCtrl bool, err error) {
+	if in.IsCtrl() {
+		ctrl, err = in.Ctrl()
+		if err != nil {
+			return ctrl, true, err
+		}
+		switch ctrl {
+		case CtrlFlush:
+			err = in.Align()
+
+		case CtrlScheme1:
+			*scheme = Scheme1
+
+		case CtrlScheme2:
+			*scheme = Scheme2
+
+		case CtrlEOR, CtrlFileMark:
+			err = in.Align()
+
+		case CtrlReset1:
+			*scheme = Scheme1
+			history.Reset()
+
+		case CtrlReset2:
+			*scheme = Scheme2
+			history.Reset()
+
+		case CtrlEndMarker:
+			if total+len(*result) == 0 {
+				// End marker at the beginning of the data.
+				err = io.EOF
+			}
+
+		default:
+			fmt.Printf("Unknown Control %s, result so far:\n%s",
+				ctrl, hex.Dump(*result))
+			err = fmt.Errorf("Invalid control symbol %s", ctrl)
+		}
+		return ctrl, true, err
+	}
+
+	if *scheme == Scheme1 {
+		err = scheme1Symbol(in, history, result)
+	} else {
+		err = scheme2Symbol(in, history, result)
+	}
+	return 0, false, err
+}
+
+// decodeFrom decodes exactly want bytes starting at bitOffset in data,
+// using scheme as the initially active scheme and a fresh History. It
+// is used by SeekableReader and DecompressParallel to decode one
+// self-contained segment of a stream - starting at a reset, where
+// History is known to be empty - independently of the bytes before it.
+// bitOffset need not fall on a byte boundary: SeekableWriter packs a
+// reset back-to-back with the data around it, exactly as Compress
+// packs its own leading reset.
+func decodeFrom(data []byte, bitOffset int64, scheme Scheme, want int64) ([]byte, error) {
+	in := &Input{data: data[bitOffset/8:], bits: 8 - int(bitOffset%8)}
 	history := NewHistory()
 	var result []byte
+	for int64(len(result)) < want {
+		if _, _, err := decodeStep(in, &scheme, history, &result, 0); err != nil {
+			return nil, err
+		}
+	}
+	return result[:want], nil
+}
+
+// Decompress decompresses a single SLDC record read from data. It is
+// a thin wrapper around NewReader for callers that have the whole
+// compressed record in memory.
+func Decompress(data []byte) ([]byte, error) {
+	r := NewReader(bytes.NewReader(data))
+	defer r.Close()
 
+	var result []byte
+	buf := make([]byte, 4096)
 	for {
-		if input.IsCtrl() {
-			ctrl, err := input.Ctrl()
-			if err != nil {
-				return nil, err
-			}
-			switch ctrl {
-			case CtrlFlush:
-				err = input.Align()
-				if err != nil {
-					return nil, err
-				}
-
-			case CtrlScheme1:
-				scheme = Scheme1
-
-			case CtrlScheme2:
-				scheme = Scheme2
-
-			case CtrlEOR:
-				err = input.Align()
-				if err != nil {
-					return nil, err
-				}
-				return result, nil
-
-			case CtrlReset1:
-				scheme = Scheme1
-				history.Reset()
-
-			case CtrlReset2:
-				scheme = Scheme2
-				history.Reset()
-
-			case CtrlEndMarker:
-				if len(result) == 0 {
-					// End marker at the beginning of the data.
-					return nil, io.EOF
-				}
-
-			default:
-				fmt.Printf("Unknown Control %s, result so far:\n%s",
-					ctrl, hex.Dump(result))
-				return nil, fmt.Errorf("Invalid control symbol %s", ctrl)
-			}
-		} else if scheme == Scheme1 {
-			val, err := input.Get(1)
-			if err != nil {
-				return nil, err
-			}
-			if val == 0 {
-				// Literal 1 Data Symbols
-				val, err = input.Get(8)
-				if err != nil {
-					return nil, err
-				}
-				history.Add(byte(val))
-				result = append(result, byte(val))
-			} else {
-				// Copy Pointer Data Symbols
-				var ones int
-				for ones = 0; ones < 4; ones++ {
-					val, err := input.Get(1)
-					if err != nil {
-						return nil, err
-					}
-					if val == 0 {
-						break
-					}
-				}
-				var base int
-				var bits int
-				switch ones {
-				case 0:
-					// 0 x
-					base = 2
-					bits = 1
-				case 1:
-					// 10 xx
-					base = 4
-					bits = 2
-				case 2:
-					// 110 xxx
-					base = 8
-					bits = 3
-				case 3:
-					// 1110 xxxx
-					base = 16
-					bits = 4
-				case 4:
-					// 1111 xxxxxxxx
-					base = 32
-					bits = 8
-				}
-				val, err = input.Get(bits)
-				if err != nil {
-					return nil, err
-				}
-				matchCount := base + int(val)
-				if matchCount > 271 {
-					return nil, fmt.Errorf("Invalid match count %d", matchCount)
-				}
-				val, err := input.Get(10)
-				if err != nil {
-					return nil, err
-				}
-				displacement := int(val)
-				var b byte
-				for matchCount > 0 {
-					b, displacement = history.Get(displacement)
-					history.Add(b)
-					result = append(result, b)
-					matchCount--
-				}
+		n, err := r.Read(buf)
+		result = append(result, buf[:n]...)
+		switch err {
+		case nil:
+		case ErrEndOfRecord:
+			return result, nil
+		case io.EOF:
+			if len(result) == 0 {
+				return nil, io.EOF
 			}
-		} else {
-			fmt.Printf("- Scheme %d rules\n", scheme)
-			return nil, fmt.Errorf("Scheme 2 rules not implemented yet")
+			return result, nil
+		default:
+			return nil, err
 		}
 	}
 }
@@ -0,0 +1,211 @@
+//
+// decompress_test.go
+//
+// Copyright (c) 2018 Markku Rossi
+//
+// All rights reserved.
+//
+
+package sldc
+
+import (
+	"bytes"
+	"testing"
+)
+
+// bitWriter is a minimal MSB-first bit packer used to construct test
+// bitstreams by hand, mirroring the bit order that Input.Get expects.
+type bitWriter struct {
+	buf  []byte
+	acc  uint32
+	bits int
+}
+
+func (w *bitWriter) put(val uint32, bits int) {
+	w.acc = (w.acc << uint(bits)) | (val & ((1 << uint(bits)) - 1))
+	w.bits += bits
+	for w.bits >= 8 {
+		shift := uint(w.bits - 8)
+		w.buf = append(w.buf, byte(w.acc>>shift))
+		w.acc &= (1 << shift) - 1
+		w.bits -= 8
+	}
+}
+
+func (w *bitWriter) ctrl(c Ctrl) {
+	w.put(0x1ff, 9)
+	w.put(uint32(c), 4)
+}
+
+func (w *bitWriter) literal(b byte) {
+	w.put(0, 1)
+	w.put(uint32(b), 8)
+}
+
+// copyScheme1 emits a Scheme 1 copy pointer for the given match length
+// and displacement.
+func (w *bitWriter) copyScheme1(length, displacement int) {
+	w.put(1, 1)
+	switch {
+	case length < 4:
+		w.put(0, 1)
+		w.put(uint32(length-2), 1)
+	case length < 8:
+		w.put(2, 2)
+		w.put(uint32(length-4), 2)
+	case length < 16:
+		w.put(6, 3)
+		w.put(uint32(length-8), 3)
+	case length < 32:
+		w.put(14, 4)
+		w.put(uint32(length-16), 4)
+	default:
+		w.put(15, 4)
+		w.put(uint32(length-32), 8)
+	}
+	w.put(uint32(displacement), 10)
+}
+
+// copyScheme2 emits a Scheme 2 copy pointer for the given match length
+// and displacement.
+func (w *bitWriter) copyScheme2(length, displacement int) {
+	w.put(1, 1)
+	if length < 18 {
+		w.put(0, 1)
+		w.put(uint32(length-2), 4)
+	} else {
+		w.put(1, 1)
+		w.put(uint32(length-18), 8)
+	}
+	w.put(uint32(displacement), 10)
+}
+
+// bytes pads the accumulated bits out to the next byte and returns the
+// buffer. It does not perform the 4-byte Align() padding; tests append
+// that explicitly where the decoder requires it (i.e. around Flush and
+// EOR).
+func (w *bitWriter) bytes() []byte {
+	for w.bits%8 != 0 {
+		w.put(0, 1)
+	}
+	return w.buf
+}
+
+// align4 pads the buffer with zero bytes up to the next 4-byte
+// boundary, matching what Input.Align expects to find after a Flush or
+// EOR control word.
+func align4(buf []byte) []byte {
+	for len(buf)%4 != 0 {
+		buf = append(buf, 0)
+	}
+	return buf
+}
+
+func TestDecompressScheme2Literals(t *testing.T) {
+	var w bitWriter
+	w.ctrl(CtrlScheme2)
+	w.literal('h')
+	w.literal('i')
+	w.ctrl(CtrlEOR)
+	data := align4(w.bytes())
+
+	got, err := Decompress(data)
+	if err != nil {
+		t.Fatalf("Decompress failed: %s", err)
+	}
+	if !bytes.Equal(got, []byte("hi")) {
+		t.Errorf("got %q, want %q", got, "hi")
+	}
+}
+
+func TestDecompressInterleavedSchemes(t *testing.T) {
+	tests := []struct {
+		name  string
+		build func(w *bitWriter)
+		want  []byte
+	}{
+		{
+			name: "scheme1 literal then switch to scheme2 literal",
+			build: func(w *bitWriter) {
+				w.literal('a')
+				w.ctrl(CtrlScheme2)
+				w.literal('b')
+				w.ctrl(CtrlEOR)
+			},
+			want: []byte("ab"),
+		},
+		{
+			name: "scheme2 copy pointer after scheme1 history",
+			build: func(w *bitWriter) {
+				w.literal('a')
+				w.literal('b')
+				w.literal('c')
+				w.ctrl(CtrlScheme2)
+				// Copy "abc" starting at history offset 0.
+				w.copyScheme2(3, 0)
+				w.ctrl(CtrlEOR)
+			},
+			want: []byte("abcabc"),
+		},
+		{
+			name: "switch back to scheme1 after scheme2",
+			build: func(w *bitWriter) {
+				w.ctrl(CtrlScheme2)
+				w.literal('x')
+				w.ctrl(CtrlScheme1)
+				w.literal('y')
+				w.ctrl(CtrlEOR)
+			},
+			want: []byte("xy"),
+		},
+		{
+			name: "scheme2 copy pointer with length 18",
+			build: func(w *bitWriter) {
+				for _, b := range []byte("0123456789abcdefgh") {
+					w.literal(b)
+				}
+				w.ctrl(CtrlScheme2)
+				w.copyScheme2(18, 0)
+				w.ctrl(CtrlEOR)
+			},
+			want: append([]byte("0123456789abcdefgh"), []byte("0123456789abcdefgh")...),
+		},
+		{
+			name: "CtrlReset2 selects scheme2 from the start of the stream",
+			build: func(w *bitWriter) {
+				w.ctrl(CtrlReset2)
+				w.literal('z')
+				w.ctrl(CtrlEOR)
+			},
+			want: []byte("z"),
+		},
+		{
+			name: "CtrlReset1 restores scheme1 and clears history",
+			build: func(w *bitWriter) {
+				w.ctrl(CtrlReset2)
+				w.literal('p')
+				w.ctrl(CtrlReset1)
+				w.literal('q')
+				w.copyScheme1(2, 0)
+				w.ctrl(CtrlEOR)
+			},
+			want: []byte("pqqq"),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var w bitWriter
+			test.build(&w)
+			data := align4(w.bytes())
+
+			got, err := Decompress(data)
+			if err != nil {
+				t.Fatalf("Decompress failed: %s", err)
+			}
+			if !bytes.Equal(got, test.want) {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
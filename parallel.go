@@ -0,0 +1,190 @@
+//
+// parallel.go
+//
+// Copyright (c) 2018 Markku Rossi
+//
+// All rights reserved.
+//
+
+package sldc
+
+import (
+	"fmt"
+	"io"
+	"sync"
+)
+
+// boundary records one point in a compressed stream where History is
+// known to be empty - the very start of the stream, a CtrlEOR/
+// CtrlFileMark, or a CtrlReset1/CtrlReset2 - giving DecompressParallel
+// a segment it can decode independently of everything before it.
+// compressedOffset is a bit offset: a reset, unlike CtrlEOR/
+// CtrlFileMark, is not byte-aligned - SeekableWriter packs it
+// back-to-back with the data around it, just like Compress's own
+// leading reset.
+type boundary struct {
+	compressedOffset   int64
+	decompressedOffset int64
+	scheme             Scheme
+}
+
+// scanSymbolLength reads one literal or copy pointer data symbol from
+// in under scheme, advancing past it without decoding its content,
+// and returns the number of decompressed bytes it produces.
+func scanSymbolLength(in *Input, scheme Scheme) (int, error) {
+	val, err := in.Get(1)
+	if err != nil {
+		return 0, err
+	}
+	if val == 0 {
+		// Literal Data Symbols.
+		if _, err := in.Get(8); err != nil {
+			return 0, err
+		}
+		return 1, nil
+	}
+
+	// Copy Pointer Data Symbols.
+	maxOnes := 4
+	if scheme == Scheme2 {
+		maxOnes = 1
+	}
+	var ones int
+	for ones = 0; ones < maxOnes; ones++ {
+		v, err := in.Get(1)
+		if err != nil {
+			return 0, err
+		}
+		if v == 0 {
+			break
+		}
+	}
+	base, bits := lengthBand(scheme, ones)
+	val, err = in.Get(bits)
+	if err != nil {
+		return 0, err
+	}
+	matchCount := base + int(val)
+	if matchCount > maxMatchLen {
+		return 0, fmt.Errorf("Invalid match count %d", matchCount)
+	}
+	if _, err := in.Get(10); err != nil {
+		return 0, err
+	}
+	return matchCount, nil
+}
+
+// scanBoundaries scans data once, locating every reset and
+// end-of-record boundary - without decoding any literal or copy
+// pointer content - and returns the segment boundaries found together
+// with the stream's total decompressed length. A reset is recorded at
+// the bit offset where its control word starts, since unlike
+// CtrlEOR/CtrlFileMark it need not land on a byte boundary.
+func scanBoundaries(data []byte) ([]boundary, int64, error) {
+	in := NewInput(data)
+	scheme := Scheme1
+	var bounds []boundary
+	var decoded int64
+
+	for in.ofs < len(in.data) {
+		if in.IsCtrl() {
+			preCtrl := in.BitOffset()
+			ctrl, err := in.Ctrl()
+			if err != nil {
+				return nil, 0, err
+			}
+			switch ctrl {
+			case CtrlFlush, CtrlEOR, CtrlFileMark:
+				err = in.Align()
+			case CtrlScheme1, CtrlReset1:
+				scheme = Scheme1
+			case CtrlScheme2, CtrlReset2:
+				scheme = Scheme2
+			case CtrlEndMarker:
+			default:
+				err = fmt.Errorf("Invalid control symbol %s", ctrl)
+			}
+			if err != nil {
+				return nil, 0, err
+			}
+			switch ctrl {
+			case CtrlReset1, CtrlReset2:
+				bounds = append(bounds, boundary{preCtrl, decoded, scheme})
+			case CtrlEOR, CtrlFileMark:
+				if in.ofs < len(in.data) {
+					bounds = append(bounds, boundary{in.BitOffset(), decoded, scheme})
+				}
+			}
+			continue
+		}
+
+		if len(bounds) == 0 {
+			bounds = append(bounds, boundary{0, 0, scheme})
+		}
+		n, err := scanSymbolLength(in, scheme)
+		if err != nil {
+			return nil, 0, err
+		}
+		decoded += int64(n)
+	}
+	return bounds, decoded, nil
+}
+
+// DecompressParallel decompresses data using up to workers goroutines,
+// scanning the stream once to find its reset and end-of-record
+// boundaries - each an independent decode start point - and decoding
+// the segments between them concurrently into a pre-sized output
+// slice. It produces the same result as Decompress (or, for streams
+// with more than one record, their concatenation), except that a
+// stream which decodes to no bytes at all yields io.EOF, even where
+// Decompress returns an empty record without error.
+func DecompressParallel(data []byte, workers int) ([]byte, error) {
+	if workers < 1 {
+		workers = 1
+	}
+
+	bounds, total, err := scanBoundaries(data)
+	if err != nil {
+		return nil, err
+	}
+	if total == 0 {
+		return nil, io.EOF
+	}
+
+	result := make([]byte, total)
+	errs := make([]error, len(bounds))
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				b := bounds[i]
+				want := total - b.decompressedOffset
+				if i+1 < len(bounds) {
+					want = bounds[i+1].decompressedOffset - b.decompressedOffset
+				}
+				segment, err := decodeFrom(data, b.compressedOffset, b.scheme, want)
+				if err != nil {
+					errs[i] = err
+					continue
+				}
+				copy(result[b.decompressedOffset:], segment)
+			}
+		}()
+	}
+	for i := range bounds {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
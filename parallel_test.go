@@ -0,0 +1,141 @@
+//
+// parallel_test.go
+//
+// Copyright (c) 2018 Markku Rossi
+//
+// All rights reserved.
+//
+
+package sldc
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// multiRecordCorpus builds a compressed, multi-record stream using
+// Writer so DecompressParallel has several reset/EOR boundaries to
+// split on.
+func multiRecordCorpus(t testing.TB) ([]byte, [][]byte) {
+	t.Helper()
+	records := [][]byte{
+		[]byte(strings.Repeat("the quick brown fox jumps over the lazy dog. ", 300)),
+		bytes.Repeat([]byte("ab"), 2000),
+		[]byte(strings.Repeat("wide vocabulary text with little repetition here ", 150)),
+		bytes.Repeat([]byte("z"), 5000),
+	}
+
+	var compressed bytes.Buffer
+	w := NewWriter(&compressed).(*Writer)
+	for i, rec := range records {
+		if _, err := w.Write(rec); err != nil {
+			t.Fatalf("Write failed: %s", err)
+		}
+		if i < len(records)-1 {
+			if err := w.EndRecord(); err != nil {
+				t.Fatalf("EndRecord failed: %s", err)
+			}
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %s", err)
+	}
+	return compressed.Bytes(), records
+}
+
+func TestDecompressParallel(t *testing.T) {
+	data, records := multiRecordCorpus(t)
+	var want []byte
+	for _, rec := range records {
+		want = append(want, rec...)
+	}
+
+	for _, workers := range []int{1, 2, 4, 8} {
+		got, err := DecompressParallel(data, workers)
+		if err != nil {
+			t.Fatalf("DecompressParallel(workers=%d) failed: %s", workers, err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("DecompressParallel(workers=%d) = %d bytes, want %d bytes",
+				workers, len(got), len(want))
+		}
+	}
+}
+
+// resetOnlyCorpus builds a compressed stream using SeekableWriter so
+// DecompressParallel has only reset boundaries to split on - no EOR or
+// FileMark - unlike multiRecordCorpus.
+func resetOnlyCorpus(t testing.TB) ([]byte, []byte) {
+	t.Helper()
+	data := []byte(strings.Repeat("the quick brown fox jumps over the lazy dog. ", 200))
+
+	var compressed bytes.Buffer
+	w := NewSeekableWriter(&compressed, 256)
+	if _, err := w.Write(data); err != nil {
+		t.Fatalf("Write failed: %s", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %s", err)
+	}
+
+	idx, _, err := ReadIndex(bytes.NewReader(compressed.Bytes()), int64(compressed.Len()))
+	if err != nil {
+		t.Fatalf("ReadIndex failed: %s", err)
+	}
+	if len(idx) < 2 {
+		t.Fatalf("expected more than one reset boundary, got %d", len(idx))
+	}
+	trailerSize := len(idx)*indexEntrySize + 8 + 4
+	return compressed.Bytes()[:compressed.Len()-trailerSize], data
+}
+
+func TestDecompressParallelResetBoundaries(t *testing.T) {
+	data, want := resetOnlyCorpus(t)
+
+	for _, workers := range []int{1, 2, 4, 8} {
+		got, err := DecompressParallel(data, workers)
+		if err != nil {
+			t.Fatalf("DecompressParallel(workers=%d) failed: %s", workers, err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("DecompressParallel(workers=%d) = %d bytes, want %d bytes",
+				workers, len(got), len(want))
+		}
+	}
+}
+
+func TestDecompressParallelSingleRecord(t *testing.T) {
+	data := []byte(strings.Repeat("abcdefgh", 500))
+	compressed, err := Compress(data, Scheme1)
+	if err != nil {
+		t.Fatalf("Compress failed: %s", err)
+	}
+	got, err := DecompressParallel(compressed, 4)
+	if err != nil {
+		t.Fatalf("DecompressParallel failed: %s", err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Errorf("got %d bytes, want %d bytes", len(got), len(data))
+	}
+}
+
+func BenchmarkDecompressSerial(b *testing.B) {
+	data, _ := multiRecordCorpus(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := DecompressParallel(data, 1); err != nil {
+			b.Fatalf("DecompressParallel failed: %s", err)
+		}
+	}
+}
+
+func BenchmarkDecompressParallel(b *testing.B) {
+	data, _ := multiRecordCorpus(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := DecompressParallel(data, 4); err != nil {
+			b.Fatalf("DecompressParallel failed: %s", err)
+		}
+	}
+}
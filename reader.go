@@ -0,0 +1,140 @@
+//
+// reader.go
+//
+// Copyright (c) 2018 Markku Rossi
+//
+// All rights reserved.
+//
+
+package sldc
+
+import (
+	"errors"
+	"io"
+)
+
+// ErrEndOfRecord is returned by Reader.Read once the current record
+// has been fully decoded. Call NextRecord to continue reading the
+// next record.
+var ErrEndOfRecord = errors.New("sldc: end of record")
+
+// refillSize is the number of bytes requested from the underlying
+// reader each time Input runs out of data.
+const refillSize = 4096
+
+// Reader implements a streaming SLDC decoder. It decodes at record
+// (CtrlEOR / CtrlFileMark) granularity, refilling its internal bit
+// buffer from the underlying io.Reader on demand.
+type Reader struct {
+	r       io.Reader
+	in      *Input
+	scheme  Scheme
+	history *History
+	total   int
+	pending []byte
+	atEOR   bool
+	started bool
+	err     error
+}
+
+// NewReader creates a streaming SLDC decoder reading compressed data
+// from r.
+func NewReader(r io.Reader) io.ReadCloser {
+	return &Reader{
+		r:       r,
+		in:      NewInput(nil),
+		scheme:  Scheme1,
+		history: NewHistory(),
+	}
+}
+
+// refill appends more compressed bytes to the input buffer.
+func (rd *Reader) refill() error {
+	buf := make([]byte, refillSize)
+	n, err := rd.r.Read(buf)
+	if n > 0 {
+		rd.in.data = append(rd.in.data, buf[:n]...)
+	}
+	return err
+}
+
+// decode advances the decoder until the current record has produced
+// some pending bytes, reached its end, or failed.
+func (rd *Reader) decode() error {
+	for len(rd.pending) == 0 && !rd.atEOR {
+		savedOfs, savedBits := rd.in.ofs, rd.in.bits
+
+		ctrl, isCtrl, err := decodeStep(rd.in, &rd.scheme, rd.history,
+			&rd.pending, rd.total)
+		if err == ErrTruncatedInput {
+			rd.in.ofs, rd.in.bits = savedOfs, savedBits
+			if ferr := rd.refill(); ferr != nil {
+				if ferr == io.EOF {
+					if !rd.started {
+						// The stream ended cleanly between records.
+						return io.EOF
+					}
+					return io.ErrUnexpectedEOF
+				}
+				return ferr
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		rd.started = true
+		if isCtrl && (ctrl == CtrlEOR || ctrl == CtrlFileMark) {
+			rd.atEOR = true
+		}
+	}
+	return nil
+}
+
+// Read implements io.Reader. It returns decompressed bytes from the
+// current record, returning ErrEndOfRecord once the record has been
+// fully consumed. Call NextRecord to move on to the next record.
+func (rd *Reader) Read(p []byte) (int, error) {
+	if rd.err != nil {
+		return 0, rd.err
+	}
+	if len(rd.pending) == 0 {
+		if rd.atEOR {
+			return 0, ErrEndOfRecord
+		}
+		if err := rd.decode(); err != nil {
+			rd.err = err
+			return 0, err
+		}
+		if len(rd.pending) == 0 {
+			return 0, ErrEndOfRecord
+		}
+	}
+
+	n := copy(p, rd.pending)
+	rd.pending = rd.pending[n:]
+	rd.total += n
+	return n, nil
+}
+
+// NextRecord moves the reader on to the next record, so that
+// subsequent Read calls decode it. It is an error to call NextRecord
+// before the current record has been fully read.
+func (rd *Reader) NextRecord() error {
+	if rd.err != nil {
+		return rd.err
+	}
+	if !rd.atEOR {
+		return errors.New("sldc: NextRecord called before end of record")
+	}
+	rd.atEOR = false
+	rd.started = false
+	return nil
+}
+
+// Close releases the reader's resources. The underlying io.Reader is
+// not closed.
+func (rd *Reader) Close() error {
+	rd.err = errors.New("sldc: Reader is closed")
+	return nil
+}
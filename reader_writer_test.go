@@ -0,0 +1,125 @@
+//
+// reader_writer_test.go
+//
+// Copyright (c) 2018 Markku Rossi
+//
+// All rights reserved.
+//
+
+package sldc
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestWriterReaderRoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		writes [][]byte
+	}{
+		{
+			name:   "single write",
+			writes: [][]byte{[]byte("the quick brown fox jumps over the lazy dog")},
+		},
+		{
+			name: "many small writes",
+			writes: [][]byte{
+				[]byte("abcabc"),
+				[]byte("abcabc"),
+				[]byte("xyz"),
+				bytes.Repeat([]byte("z"), 300),
+			},
+		},
+		{
+			name:   "empty record",
+			writes: nil,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var compressed bytes.Buffer
+			w := NewWriter(&compressed)
+			var want []byte
+			for _, p := range test.writes {
+				n, err := w.Write(p)
+				if err != nil || n != len(p) {
+					t.Fatalf("Write(%d bytes) = %d, %s", len(p), n, err)
+				}
+				want = append(want, p...)
+			}
+			if err := w.Close(); err != nil {
+				t.Fatalf("Close failed: %s", err)
+			}
+
+			r := NewReader(&compressed)
+			defer r.Close()
+			got, err := readRecord(r)
+			if err != nil {
+				t.Fatalf("readRecord failed: %s", err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("got %d bytes, want %d bytes", len(got), len(want))
+			}
+		})
+	}
+}
+
+func TestWriterMultipleRecords(t *testing.T) {
+	var compressed bytes.Buffer
+	w := NewWriter(&compressed).(*Writer)
+
+	records := [][]byte{
+		[]byte("first record"),
+		[]byte("second record, a little longer than the first"),
+		[]byte("third"),
+	}
+	for i, rec := range records {
+		if _, err := w.Write(rec); err != nil {
+			t.Fatalf("Write failed: %s", err)
+		}
+		if i < len(records)-1 {
+			if err := w.EndRecord(); err != nil {
+				t.Fatalf("EndRecord failed: %s", err)
+			}
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %s", err)
+	}
+
+	r := NewReader(&compressed)
+	defer r.Close()
+	for i, want := range records {
+		got, err := readRecord(r)
+		if err != nil {
+			t.Fatalf("record %d: readRecord failed: %s", i, err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("record %d: got %q, want %q", i, got, want)
+		}
+		if i < len(records)-1 {
+			if err := r.(*Reader).NextRecord(); err != nil {
+				t.Fatalf("record %d: NextRecord failed: %s", i, err)
+			}
+		}
+	}
+}
+
+// readRecord reads a single SLDC record to completion from r.
+func readRecord(r io.Reader) ([]byte, error) {
+	var result []byte
+	buf := make([]byte, 64)
+	for {
+		n, err := r.Read(buf)
+		result = append(result, buf[:n]...)
+		if err == ErrEndOfRecord || err == io.EOF {
+			return result, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
@@ -0,0 +1,338 @@
+//
+// seekable.go
+//
+// Copyright (c) 2018 Markku Rossi
+//
+// All rights reserved.
+//
+
+package sldc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// IndexEntry records one CtrlReset1/CtrlReset2 boundary in an SLDC
+// container: the bit offset in the compressed stream where the reset
+// begins (not necessarily byte-aligned - SeekableWriter packs a reset
+// back-to-back with the data around it, exactly as Compress packs its
+// own leading reset), the cumulative decompressed byte offset it
+// starts at, and the scheme the reset makes active.
+type IndexEntry struct {
+	CompressedOffset   int64
+	DecompressedOffset int64
+	Scheme             Scheme
+}
+
+// Index is an ordered list of a container's reset boundaries, sorted
+// by DecompressedOffset.
+type Index []IndexEntry
+
+// indexEntrySize is the serialized size, in bytes, of one IndexEntry.
+const indexEntrySize = 8 + 8 + 1
+
+// WriteIndex serializes idx as a trailer - entries, the total
+// decompressed size and an entry count - and writes it to w.
+func WriteIndex(w io.Writer, idx Index, totalDecompressed int64) error {
+	buf := make([]byte, len(idx)*indexEntrySize+8+4)
+	for i, e := range idx {
+		b := buf[i*indexEntrySize:]
+		binary.BigEndian.PutUint64(b[0:8], uint64(e.CompressedOffset))
+		binary.BigEndian.PutUint64(b[8:16], uint64(e.DecompressedOffset))
+		b[16] = byte(e.Scheme)
+	}
+	tail := buf[len(idx)*indexEntrySize:]
+	binary.BigEndian.PutUint64(tail[0:8], uint64(totalDecompressed))
+	binary.BigEndian.PutUint32(tail[8:12], uint32(len(idx)))
+	_, err := w.Write(buf)
+	return err
+}
+
+// ReadIndex reads a trailer previously written by WriteIndex from the
+// end of the size bytes available through r.
+func ReadIndex(r io.ReaderAt, size int64) (Index, int64, error) {
+	const footerSize = 8 + 4
+	if size < footerSize {
+		return nil, 0, fmt.Errorf("sldc: truncated index trailer")
+	}
+	var footer [footerSize]byte
+	if _, err := r.ReadAt(footer[:], size-footerSize); err != nil {
+		return nil, 0, err
+	}
+	total := int64(binary.BigEndian.Uint64(footer[0:8]))
+	n := int(binary.BigEndian.Uint32(footer[8:12]))
+
+	trailerSize := int64(n*indexEntrySize) + footerSize
+	if trailerSize > size {
+		return nil, 0, fmt.Errorf("sldc: truncated index trailer")
+	}
+
+	idx := make(Index, n)
+	if n > 0 {
+		buf := make([]byte, n*indexEntrySize)
+		if _, err := r.ReadAt(buf, size-trailerSize); err != nil {
+			return nil, 0, err
+		}
+		for i := range idx {
+			b := buf[i*indexEntrySize:]
+			idx[i] = IndexEntry{
+				CompressedOffset:   int64(binary.BigEndian.Uint64(b[0:8])),
+				DecompressedOffset: int64(binary.BigEndian.Uint64(b[8:16])),
+				Scheme:             Scheme(b[16]),
+			}
+		}
+	}
+	return idx, total, nil
+}
+
+// SeekableWriter writes a single, long-lived SLDC record to an
+// underlying io.Writer, inserting a CtrlReset1/CtrlReset2 every
+// interval decompressed bytes so that a SeekableReader can later jump
+// close to any target offset. Close terminates the record and
+// appends the block index trailer.
+type SeekableWriter struct {
+	w          io.Writer
+	interval   int64
+	scheme     Scheme
+	out        *Output
+	sent       int
+	decoded    int64
+	sinceReset int64
+	recordOfs  int
+	index      Index
+	started    bool
+	closed     bool
+}
+
+// NewSeekableWriter creates a SeekableWriter around w, starting a new
+// reset boundary at least every interval decompressed bytes.
+func NewSeekableWriter(w io.Writer, interval int) *SeekableWriter {
+	return &SeekableWriter{
+		w:        w,
+		interval: int64(interval),
+		scheme:   Scheme1,
+		out:      NewOutput(),
+	}
+}
+
+// maybeReset starts a new reset block - recording its bit offset in
+// the index - if this is the first write or the interval since the
+// last reset has been reached. The reset is packed back-to-back with
+// the surrounding data, just like Compress's own leading reset, so it
+// is not byte-aligned and decodeFrom must be given a bit offset to
+// resume from it.
+func (sw *SeekableWriter) maybeReset() {
+	if sw.started && sw.sinceReset < sw.interval {
+		return
+	}
+	sw.index = append(sw.index, IndexEntry{
+		CompressedOffset:   int64(len(sw.out.data))*8 + int64(sw.out.bits),
+		DecompressedOffset: sw.decoded,
+		Scheme:             sw.scheme,
+	})
+	sw.out.Ctrl(resetCtrl(sw.scheme))
+	sw.started = true
+	sw.sinceReset = 0
+	sw.recordOfs = 0
+}
+
+// Write compresses p and appends it to the container, splitting it
+// internally at interval boundaries so that a fresh reset lands every
+// ~interval decompressed bytes regardless of how the caller chunks
+// its writes.
+func (sw *SeekableWriter) Write(p []byte) (int, error) {
+	if sw.closed {
+		return 0, errors.New("sldc: write to closed SeekableWriter")
+	}
+	total := len(p)
+	sw.maybeReset()
+	for len(p) > 0 {
+		chunk := p
+		if remaining := sw.interval - sw.sinceReset; int64(len(chunk)) > remaining {
+			chunk = chunk[:remaining]
+		}
+		sw.writeChunk(chunk)
+		p = p[len(chunk):]
+		if len(p) > 0 {
+			sw.maybeReset()
+		}
+	}
+	if err := sw.drain(); err != nil {
+		return 0, err
+	}
+	return total, nil
+}
+
+// writeChunk compresses p - a slice bounded to end at the next reset
+// boundary - and appends it to the current block.
+func (sw *SeekableWriter) writeChunk(p []byte) {
+	for _, sym := range findMatches(p) {
+		if sym.literal {
+			sw.out.writeLiteral(sym.b)
+		} else {
+			displacement := (sw.recordOfs + sym.displacement) % windowSize
+			sw.out.writeCopy(sw.scheme, sym.length, displacement)
+		}
+	}
+	sw.recordOfs += len(p)
+	sw.decoded += int64(len(p))
+	sw.sinceReset += int64(len(p))
+}
+
+// drain writes any complete bytes accumulated in out but not yet sent
+// to the underlying writer.
+func (sw *SeekableWriter) drain() error {
+	if sw.sent >= len(sw.out.data) {
+		return nil
+	}
+	_, err := sw.w.Write(sw.out.data[sw.sent:])
+	sw.sent = len(sw.out.data)
+	return err
+}
+
+// Close terminates the record with a CtrlEOR, flushes any remaining
+// compressed bytes and appends the block index trailer.
+func (sw *SeekableWriter) Close() error {
+	if sw.closed {
+		return nil
+	}
+	sw.closed = true
+	sw.out.Ctrl(CtrlEOR)
+	sw.out.Align()
+	if err := sw.drain(); err != nil {
+		return err
+	}
+	return WriteIndex(sw.w, sw.index, sw.decoded)
+}
+
+// SeekableReader provides random access into an SLDC container
+// produced by SeekableWriter, using its trailing block index to
+// start decoding from the reset nearest a requested offset instead of
+// from the start of the stream.
+type SeekableReader struct {
+	data  []byte
+	index Index
+	size  int64
+	pos   int64
+}
+
+// NewSeekableReader creates a SeekableReader over a complete SLDC
+// container - compressed payload followed by its index trailer, as
+// produced by SeekableWriter.
+func NewSeekableReader(data []byte) (*SeekableReader, error) {
+	idx, total, err := ReadIndex(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+	if len(idx) == 0 {
+		return nil, errors.New("sldc: container has no reset boundaries")
+	}
+	trailerSize := len(idx)*indexEntrySize + 8 + 4
+	return &SeekableReader{
+		data:  data[:len(data)-trailerSize],
+		index: idx,
+		size:  total,
+	}, nil
+}
+
+// blockIndexFor returns the position in sr.index of the reset
+// boundary that the given decompressed offset falls into.
+func (sr *SeekableReader) blockIndexFor(off int64) int {
+	i := sort.Search(len(sr.index), func(i int) bool {
+		return sr.index[i].DecompressedOffset > off
+	})
+	if i == 0 {
+		i = 1
+	}
+	return i - 1
+}
+
+// decodeBlock decodes the entire reset block starting at sr.index[bi]
+// - a fresh Input and History, as left by the reset - and returns its
+// decompressed bytes. Each block is bounded by the DecompressedOffset
+// of the next reset (or the container's total size for the last
+// block), so decoding stops exactly at the block's end without
+// needing to read past it into the next reset.
+func (sr *SeekableReader) decodeBlock(bi int) ([]byte, error) {
+	entry := sr.index[bi]
+	var blockLen int64
+	if bi+1 < len(sr.index) {
+		blockLen = sr.index[bi+1].DecompressedOffset - entry.DecompressedOffset
+	} else {
+		blockLen = sr.size - entry.DecompressedOffset
+	}
+	return decodeFrom(sr.data, entry.CompressedOffset, entry.Scheme, blockLen)
+}
+
+// ReadAt implements io.ReaderAt, decoding forward from the reset
+// boundary nearest off, one whole block at a time.
+func (sr *SeekableReader) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("sldc: negative offset")
+	}
+	if off >= sr.size {
+		return 0, io.EOF
+	}
+
+	bi := sr.blockIndexFor(off)
+	localOff := off - sr.index[bi].DecompressedOffset
+
+	n := 0
+	for n < len(p) {
+		block, err := sr.decodeBlock(bi)
+		if err != nil {
+			return n, err
+		}
+		if localOff >= int64(len(block)) {
+			break
+		}
+		copied := copy(p[n:], block[localOff:])
+		n += copied
+		localOff = 0
+		bi++
+		if bi >= len(sr.index) {
+			break
+		}
+	}
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// Seek implements io.Seeker.
+func (sr *SeekableReader) Seek(offset int64, whence int) (int64, error) {
+	var abs int64
+	switch whence {
+	case io.SeekStart:
+		abs = offset
+	case io.SeekCurrent:
+		abs = sr.pos + offset
+	case io.SeekEnd:
+		abs = sr.size + offset
+	default:
+		return 0, fmt.Errorf("sldc: invalid whence %d", whence)
+	}
+	if abs < 0 {
+		return 0, errors.New("sldc: negative position")
+	}
+	sr.pos = abs
+	return abs, nil
+}
+
+// Read implements io.Reader over the current seek position.
+func (sr *SeekableReader) Read(p []byte) (int, error) {
+	n, err := sr.ReadAt(p, sr.pos)
+	sr.pos += int64(n)
+	return n, err
+}
+
+// Size returns the total decompressed size of the container.
+func (sr *SeekableReader) Size() int64 {
+	return sr.size
+}
@@ -0,0 +1,141 @@
+//
+// seekable_test.go
+//
+// Copyright (c) 2018 Markku Rossi
+//
+// All rights reserved.
+//
+
+package sldc
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestSeekableWriterReaderRoundTrip(t *testing.T) {
+	data := []byte(strings.Repeat("the quick brown fox jumps over the lazy dog. ", 200))
+
+	var compressed bytes.Buffer
+	w := NewSeekableWriter(&compressed, 512)
+	chunk := 37
+	for i := 0; i < len(data); i += chunk {
+		end := i + chunk
+		if end > len(data) {
+			end = len(data)
+		}
+		if _, err := w.Write(data[i:end]); err != nil {
+			t.Fatalf("Write failed: %s", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %s", err)
+	}
+
+	r, err := NewSeekableReader(compressed.Bytes())
+	if err != nil {
+		t.Fatalf("NewSeekableReader failed: %s", err)
+	}
+	if r.Size() != int64(len(data)) {
+		t.Fatalf("Size() = %d, want %d", r.Size(), len(data))
+	}
+	if len(r.index) < 2 {
+		t.Fatalf("expected more than one reset boundary, got %d", len(r.index))
+	}
+
+	offsets := []int64{0, 1, 500, 501, 1000, int64(len(data) - 50)}
+	for _, off := range offsets {
+		got := make([]byte, 50)
+		n, err := r.ReadAt(got, off)
+		if err != nil {
+			t.Fatalf("ReadAt(%d) failed: %s", off, err)
+		}
+		want := data[off : off+int64(n)]
+		if !bytes.Equal(got[:n], want) {
+			t.Errorf("ReadAt(%d) = %q, want %q", off, got[:n], want)
+		}
+	}
+}
+
+func TestSeekableWriterSplitsLargeWrites(t *testing.T) {
+	data := []byte(strings.Repeat("the quick brown fox jumps over the lazy dog. ", 200))
+
+	var compressed bytes.Buffer
+	w := NewSeekableWriter(&compressed, 256)
+	if _, err := w.Write(data); err != nil {
+		t.Fatalf("Write failed: %s", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %s", err)
+	}
+
+	r, err := NewSeekableReader(compressed.Bytes())
+	if err != nil {
+		t.Fatalf("NewSeekableReader failed: %s", err)
+	}
+	want := len(data) / 256
+	if len(r.index) < want {
+		t.Fatalf("single %d-byte Write with interval=256 produced %d index entries, want at least %d",
+			len(data), len(r.index), want)
+	}
+}
+
+func TestDecompressSeekableWriterPayload(t *testing.T) {
+	data := []byte(strings.Repeat("the quick brown fox jumps over the lazy dog. ", 200))
+
+	var compressed bytes.Buffer
+	w := NewSeekableWriter(&compressed, 256)
+	if _, err := w.Write(data); err != nil {
+		t.Fatalf("Write failed: %s", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %s", err)
+	}
+
+	idx, _, err := ReadIndex(bytes.NewReader(compressed.Bytes()), int64(compressed.Len()))
+	if err != nil {
+		t.Fatalf("ReadIndex failed: %s", err)
+	}
+	trailerSize := len(idx)*indexEntrySize + 8 + 4
+	payload := compressed.Bytes()[:compressed.Len()-trailerSize]
+
+	got, err := Decompress(payload)
+	if err != nil {
+		t.Fatalf("Decompress failed: %s", err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Errorf("Decompress mismatch: got %d bytes, want %d bytes", len(got), len(data))
+	}
+}
+
+func TestSeekableReaderSeek(t *testing.T) {
+	data := bytes.Repeat([]byte("abcdefgh"), 100)
+
+	var compressed bytes.Buffer
+	w := NewSeekableWriter(&compressed, 256)
+	if _, err := w.Write(data); err != nil {
+		t.Fatalf("Write failed: %s", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %s", err)
+	}
+
+	r, err := NewSeekableReader(compressed.Bytes())
+	if err != nil {
+		t.Fatalf("NewSeekableReader failed: %s", err)
+	}
+
+	if _, err := r.Seek(100, 0); err != nil {
+		t.Fatalf("Seek failed: %s", err)
+	}
+	got := make([]byte, 16)
+	n, err := r.Read(got)
+	if err != nil {
+		t.Fatalf("Read failed: %s", err)
+	}
+	want := data[100 : 100+n]
+	if !bytes.Equal(got[:n], want) {
+		t.Errorf("Read after Seek = %q, want %q", got[:n], want)
+	}
+}
@@ -0,0 +1,121 @@
+//
+// writer.go
+//
+// Copyright (c) 2018 Markku Rossi
+//
+// All rights reserved.
+//
+
+package sldc
+
+import (
+	"errors"
+	"io"
+)
+
+// Writer implements a streaming SLDC encoder. Bytes written between
+// NewWriter and the first EndRecord (or Close) form one record; each
+// call to Write is compressed and appended to that record immediately,
+// so matches can only reference bytes written in the same call. Call
+// Flush to force any buffered bits out without ending the record, and
+// EndRecord (or Close) to terminate it with a CtrlEOR.
+type Writer struct {
+	w         io.Writer
+	out       *Output
+	scheme    Scheme
+	sent      int
+	started   bool
+	recordOfs int
+	closed    bool
+}
+
+// NewWriter creates a streaming SLDC encoder writing compressed data
+// to w.
+func NewWriter(w io.Writer) io.WriteCloser {
+	return &Writer{
+		w:      w,
+		out:    NewOutput(),
+		scheme: Scheme1,
+	}
+}
+
+// drain writes any complete bytes accumulated in out but not yet sent
+// to the underlying writer.
+func (wr *Writer) drain() error {
+	if wr.sent >= len(wr.out.data) {
+		return nil
+	}
+	_, err := wr.w.Write(wr.out.data[wr.sent:])
+	wr.sent = len(wr.out.data)
+	return err
+}
+
+// Write compresses p and appends it to the current record.
+func (wr *Writer) Write(p []byte) (int, error) {
+	if wr.closed {
+		return 0, errors.New("sldc: write to closed Writer")
+	}
+	if !wr.started {
+		wr.out.Ctrl(resetCtrl(wr.scheme))
+		wr.started = true
+	}
+	for _, sym := range findMatches(p) {
+		if sym.literal {
+			wr.out.writeLiteral(sym.b)
+		} else {
+			displacement := (wr.recordOfs + sym.displacement) % windowSize
+			wr.out.writeCopy(wr.scheme, sym.length, displacement)
+		}
+	}
+	wr.recordOfs += len(p)
+
+	if err := wr.drain(); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Flush writes a CtrlFlush control word and aligns the output to a
+// four byte boundary, then pushes any buffered bytes to the
+// underlying writer. It does not end the current record.
+func (wr *Writer) Flush() error {
+	if wr.closed {
+		return errors.New("sldc: flush of closed Writer")
+	}
+	if !wr.started {
+		return nil
+	}
+	wr.out.Ctrl(CtrlFlush)
+	wr.out.Align()
+	return wr.drain()
+}
+
+// EndRecord terminates the current record with a CtrlEOR control
+// word and starts a new, empty record for subsequent writes.
+func (wr *Writer) EndRecord() error {
+	if wr.closed {
+		return errors.New("sldc: EndRecord on closed Writer")
+	}
+	if !wr.started {
+		return nil
+	}
+	wr.out.Ctrl(CtrlEOR)
+	wr.out.Align()
+	if err := wr.drain(); err != nil {
+		return err
+	}
+	wr.started = false
+	wr.recordOfs = 0
+	return nil
+}
+
+// Close ends the current record, if any, and closes the writer. The
+// underlying io.Writer is not closed.
+func (wr *Writer) Close() error {
+	if wr.closed {
+		return nil
+	}
+	err := wr.EndRecord()
+	wr.closed = true
+	return err
+}